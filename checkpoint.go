@@ -0,0 +1,449 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCheckCacheDuration is how long a cached check response is
+// considered fresh when the server does not send a stricter
+// Cache-Control/Expires directive.
+const defaultCheckCacheDuration = 48 * time.Hour
+
+// VersionCheckInterval is how often callCheck re-checks the checkpoint
+// server for a newer version while a UsageClient is running.
+const VersionCheckInterval = 24 * time.Hour
+
+// checkpointURL is the base URL for the checkpoint service, overridable
+// via the CHECKPOINT_URL environment variable for testing or for pointing
+// at a self-hosted mirror.
+const checkpointURL = "https://checkpoint-api.solo.io"
+
+// CheckParams are the parameters for configuring a check request.
+type CheckParams struct {
+	// Product and version are used to lookup the correct product and
+	// release information.
+	Product string
+	Version string
+
+	// Arch and OS are used to filter the results to a specific
+	// architecture and operating system. These are usually runtime.GOARCH
+	// and runtime.GOOS, respectively, but it could be overridden if
+	// needed. If these aren't set, they won't be used as filters.
+	Arch string
+	OS   string
+
+	// Signature is some random signature that should be stored and used
+	// to prevent duplicate "phone homes" from occurring. This isn't
+	// required but is recommended.
+	Signature string
+
+	// SignatureFile is a file to store and read a generated signature
+	// value from. If this is set, then it'll be read. If the file
+	// doesn't exist, then a signature will be automatically generated
+	// and stored here. If neither Signature or SignatureFile is set,
+	// then a signature will just not be used.
+	SignatureFile string
+
+	// Type is the type of project being checked.
+	Type string
+
+	// CacheFile, if specified, will cache the result of a check. The
+	// cached response is validated against the checkpoint server using
+	// standard HTTP cache semantics (If-None-Match/If-Modified-Since)
+	// rather than being treated as an opaque blob, so a fresh-looking
+	// cache entry is still confirmed with a cheap 304 round trip once
+	// CacheDuration has elapsed. CacheDuration defaults to 48 hours, but
+	// is overridden by any Cache-Control/Expires directive the server
+	// sends that is stricter than it.
+	CacheFile     string
+	CacheDuration time.Duration
+
+	// Force, if true, will force the check even if CHECKPOINT_DISABLE
+	// is set. This is ONLY USED when the user directly requests a
+	// version check, e.g. via a "version" command.
+	Force bool
+
+	// RetryPolicy controls how connection errors and 5xx responses are
+	// retried. If nil, defaultRetryPolicy() is used.
+	RetryPolicy *RetryPolicy
+
+	// HTTPClient overrides the *http.Client used to make the request. If
+	// nil, cleanhttpClient() is used.
+	HTTPClient *http.Client
+}
+
+// CheckResponse is the response for a check request.
+type CheckResponse struct {
+	Product             string
+	CurrentVersion      string `json:"current_version"`
+	CurrentReleaseDate  int    `json:"current_release_date"`
+	CurrentDownloadURL  string `json:"current_download_url"`
+	CurrentChangelogURL string `json:"current_changelog_url"`
+	ProjectWebsite      string `json:"project_website"`
+	Outdated            bool   `json:"outdated"`
+	Alerts              []*CheckAlert
+}
+
+// CheckAlert is a single alert message from a check request.
+//
+// These never have to be acted on by the user, but the UI for checkpoint
+// reporting tools should surface them.
+type CheckAlert struct {
+	ID      int
+	Date    int
+	Message string
+	URL     string
+	Level   string
+}
+
+// cacheEntry is the on-disk representation of a cached check response. It
+// stores the HTTP validators the server handed us alongside the decoded
+// body so a later check can send a conditional request instead of
+// blindly trusting a time-based TTL.
+type cacheEntry struct {
+	Response     *CheckResponse `json:"response"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+}
+
+// check checks the checkpoint server for the given product.
+func check(p *CheckParams) (*CheckResponse, error) {
+	return checkWithContext(context.Background(), p)
+}
+
+// checkWithContext is the context-aware core of check. It's split out so
+// that callers that already manage a cancellable context (such as
+// usageClient's background goroutines) can have their retries aborted on
+// shutdown instead of always running against context.Background().
+func checkWithContext(ctx context.Context, p *CheckParams) (*CheckResponse, error) {
+	return checkAgainst(ctx, p, checkpointBaseURL())
+}
+
+// checkAgainst is checkWithContext against a specific checkpoint endpoint,
+// used directly by Client to try several endpoints in turn.
+func checkAgainst(ctx context.Context, p *CheckParams, base *url.URL) (*CheckResponse, error) {
+	if disabled := os.Getenv("CHECKPOINT_DISABLE"); disabled != "" && !p.Force {
+		return &CheckResponse{}, nil
+	}
+
+	var entry *cacheEntry
+	if p.CacheFile != "" {
+		var err error
+		entry, err = readCacheEntry(p.CacheFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// If we have a cache entry and it hasn't expired according to
+		// either our own TTL or the server's directive, return it
+		// without talking to the network at all.
+		if entry != nil && entry.Response != nil && time.Now().Before(entry.ExpiresAt) {
+			return entry.Response, nil
+		}
+	}
+
+	req, err := checkRequestAgainst(p, base)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	client := p.HTTPClient
+	if client == nil {
+		client = cleanhttpClient()
+	}
+	resp, err := doWithRetry(ctx, client, req, p.RetryPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if entry == nil || entry.Response == nil {
+			return nil, fmt.Errorf("checkpoint: got 304 Not Modified with no cached response")
+		}
+		entry.ExpiresAt = cacheExpiration(resp, p.CacheDuration)
+		if p.CacheFile != "" {
+			if err := writeCacheEntry(p.CacheFile, entry); err != nil {
+				return nil, err
+			}
+		}
+		return entry.Response, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("checkpoint: unexpected status code %d", resp.StatusCode)
+	}
+
+	var result CheckResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if p.CacheFile != "" {
+		newEntry := &cacheEntry{
+			Response:     &result,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ExpiresAt:    cacheExpiration(resp, p.CacheDuration),
+		}
+		if err := writeCacheEntry(p.CacheFile, newEntry); err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
+
+// cacheExpiration determines when a check response should be considered
+// stale, preferring the server's Cache-Control/Expires directive over our
+// own CacheDuration whenever the server is stricter.
+func cacheExpiration(resp *http.Response, duration time.Duration) time.Time {
+	if duration <= 0 {
+		duration = defaultCheckCacheDuration
+	}
+	ttl := duration
+
+	if cc := resp.Header.Get("Cache-Control"); cc != "" {
+		if age, ok := maxAgeFromCacheControl(cc); ok && age < ttl {
+			ttl = age
+		}
+	} else if exp := resp.Header.Get("Expires"); exp != "" {
+		if t, err := http.ParseTime(exp); err == nil {
+			if d := time.Until(t); d < ttl {
+				ttl = d
+			}
+		}
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	}
+	return time.Now().Add(ttl)
+}
+
+// maxAgeFromCacheControl parses the max-age (preferring s-maxage) directive
+// out of a Cache-Control header value.
+func maxAgeFromCacheControl(cc string) (time.Duration, bool) {
+	var maxAge, sMaxAge string
+	for _, part := range bytesSplitComma(cc) {
+		kv := bytes.SplitN([]byte(part), []byte("="), 2)
+		key := string(bytes.TrimSpace(bytes.ToLower(kv[0])))
+		switch key {
+		case "max-age":
+			if len(kv) == 2 {
+				maxAge = string(bytes.TrimSpace(kv[1]))
+			}
+		case "s-maxage":
+			if len(kv) == 2 {
+				sMaxAge = string(bytes.TrimSpace(kv[1]))
+			}
+		}
+	}
+
+	raw := maxAge
+	if sMaxAge != "" {
+		raw = sMaxAge
+	}
+	if raw == "" {
+		return 0, false
+	}
+
+	secs, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// bytesSplitComma splits a Cache-Control header into its directives.
+func bytesSplitComma(s string) []string {
+	var out []string
+	for _, p := range bytes.Split([]byte(s), []byte(",")) {
+		out = append(out, string(bytes.TrimSpace(p)))
+	}
+	return out
+}
+
+func readCacheEntry(path string) (*cacheEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entry cacheEntry
+	if err := json.NewDecoder(f).Decode(&entry); err != nil {
+		// A corrupt or legacy cache file shouldn't be fatal, just
+		// treated as a cache miss.
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func writeCacheEntry(path string, entry *cacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// checkRequest builds the outgoing HTTP request for a check call against
+// the default (CHECKPOINT_URL) endpoint.
+func checkRequest(p *CheckParams) (*http.Request, error) {
+	return checkRequestAgainst(p, checkpointBaseURL())
+}
+
+// checkRequestAgainst builds the outgoing HTTP request for a check call
+// against a specific base URL, so a Client can try multiple endpoints.
+func checkRequestAgainst(p *CheckParams, base *url.URL) (*http.Request, error) {
+	arch := p.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	os_ := p.OS
+	if os_ == "" {
+		os_ = runtime.GOOS
+	}
+
+	signature := p.Signature
+	if signature == "" && p.SignatureFile != "" {
+		var err error
+		signature, err = checkSignature(p.SignatureFile)
+		if err != nil {
+			signature, err = generateSignature(p.SignatureFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	u := *base
+	u.Path = fmt.Sprintf("/v1/check/%s", p.Product)
+
+	v := url.Values{}
+	v.Set("version", p.Version)
+	v.Set("arch", arch)
+	v.Set("os", os_)
+	v.Set("signature", signature)
+	if p.Type != "" {
+		v.Set("product", p.Type)
+	}
+	u.RawQuery = v.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// checkpointBaseURL returns the base URL to use for all checkpoint
+// requests, honoring the CHECKPOINT_URL environment variable override.
+func checkpointBaseURL() *url.URL {
+	raw := checkpointURL
+	if v := os.Getenv("CHECKPOINT_URL"); v != "" {
+		raw = v
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		// The override is operator-controlled; fall back to the
+		// default rather than panicking on a typo.
+		u, _ = url.Parse(checkpointURL)
+	}
+	return u
+}
+
+// cleanhttpClient returns an http.Client configured from the
+// CHECKPOINT_TIMEOUT environment variable, if set.
+func cleanhttpClient() *http.Client {
+	client := &http.Client{}
+	if v := os.Getenv("CHECKPOINT_TIMEOUT"); v != "" {
+		if timeout, err := strconv.Atoi(v); err == nil {
+			client.Timeout = time.Duration(timeout)
+		}
+	}
+	return client
+}
+
+// checkInterval runs check on the given interval, invoking cb with the
+// result of each check. It returns a channel that, when closed, stops
+// the interval loop; the loop also stops if ctx is canceled. If wg is
+// non-nil, it's incremented for the lifetime of the loop's goroutine so
+// callers can join it.
+func checkInterval(ctx context.Context, p *CheckParams, interval time.Duration, cb func(*CheckResponse, error), wg *sync.WaitGroup) chan struct{} {
+	doneCh := make(chan struct{})
+
+	if os.Getenv("CHECKPOINT_DISABLE") != "" {
+		return doneCh
+	}
+
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+		for {
+			select {
+			case <-time.After(randomStagger(interval, 25)):
+				resp, err := checkWithContext(ctx, p)
+				cb(resp, err)
+			case <-doneCh:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return doneCh
+}
+
+// randomStagger returns intv staggered by a random jitter of up to pct
+// percent in either direction, to avoid a thundering herd of clients all
+// checking in at the exact same moment.
+func randomStagger(intv time.Duration, pct int) time.Duration {
+	if pct <= 0 {
+		pct = 25
+	}
+
+	delta := int64(intv) * int64(pct) / 100
+	if delta <= 0 {
+		return intv
+	}
+
+	offset := rand.Int63n(2*delta+1) - delta
+	return intv + time.Duration(offset)
+}
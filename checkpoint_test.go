@@ -1,6 +1,7 @@
 package checkpoint
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -175,6 +177,67 @@ func TestCheck_cacheNested(t *testing.T) {
 	}
 }
 
+func TestCheck_cacheNoServerContact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		json.NewEncoder(w).Encode(baseExpectedResponse)
+	}))
+	defer srv.Close()
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	os.Setenv("CHECKPOINT_URL", srv.URL)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	params := &CheckParams{
+		Product:   "test",
+		Version:   "1.0",
+		CacheFile: filepath.Join(dir, "cache"),
+	}
+
+	if _, err := check(params); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := check(params); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected 1 server hit within the cache TTL, got %d", got)
+	}
+}
+
+func TestCacheExpiration_maxAgeCannotExtendTTL(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Cache-Control", "max-age=7200") // 2h, longer than the 1h CacheDuration below
+
+	duration := time.Hour
+	got := cacheExpiration(resp, duration)
+
+	maxAllowed := time.Now().Add(duration + time.Minute)
+	if got.After(maxAllowed) {
+		t.Fatalf("expected a server max-age longer than CacheDuration to be ignored, got expiry %v (allowed up to %v)", got, maxAllowed)
+	}
+}
+
+func TestCacheExpiration_maxAgeCanShortenTTL(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Cache-Control", "max-age=60") // 1m, shorter than the 1h CacheDuration below
+
+	duration := time.Hour
+	got := cacheExpiration(resp, duration)
+
+	maxAllowed := time.Now().Add(2 * time.Minute)
+	if got.After(maxAllowed) {
+		t.Fatalf("expected the stricter server max-age to shorten the TTL, got expiry %v (allowed up to %v)", got, maxAllowed)
+	}
+}
+
 func TestCheckInterval(t *testing.T) {
 	expected := baseExpectedResponse
 	expected.Outdated = false
@@ -197,7 +260,7 @@ func TestCheckInterval(t *testing.T) {
 		}
 	}
 
-	doneCh := checkInterval(params, 500*time.Millisecond, checkFn)
+	doneCh := checkInterval(context.Background(), params, 500*time.Millisecond, checkFn, nil)
 	defer close(doneCh)
 
 	select {
@@ -221,7 +284,7 @@ func TestCheckInterval_disabled(t *testing.T) {
 		defer close(calledCh)
 	}
 
-	doneCh := checkInterval(params, 500*time.Millisecond, checkFn)
+	doneCh := checkInterval(context.Background(), params, 500*time.Millisecond, checkFn, nil)
 	defer close(doneCh)
 
 	select {
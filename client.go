@@ -1,32 +1,125 @@
 package checkpoint
 
 import (
+	"context"
+	"net/http"
+	"sync"
 	"time"
 )
 
 // A simple interface for interacting with the checkpoint server, for reporting and version checking
 type UsageClient interface {
-	Start(name, version string)
+	Start(name, version string) StopFunc
 }
 
+// StopFunc stops a running UsageClient's background goroutines, canceling
+// any in-flight request and waiting for the goroutines to exit before
+// returning.
+type StopFunc func() error
+
 var _ UsageClient = NewUsageClient()
 
-func NewUsageClient() *usageClient {
-	return &usageClient{}
+// Option configures a usageClient created by NewUsageClient.
+type Option func(*usageClient)
+
+// WithHTTPClient overrides the *http.Client used for check and report
+// requests. If unset, the client derived from CHECKPOINT_TIMEOUT is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *usageClient) { c.httpClient = client }
+}
+
+// WithContext sets the parent context for the client's background work.
+// Start derives a cancellable child context from it, so canceling the
+// parent (in addition to calling the StopFunc returned by Start) stops
+// the client.
+func WithContext(ctx context.Context) Option {
+	return func(c *usageClient) { c.parentCtx = ctx }
+}
+
+// WithLogger routes the client's check/report failures through a
+// structured logger instead of dropping them silently.
+func WithLogger(logger func(level, msg string, kv ...interface{})) Option {
+	return func(c *usageClient) { c.logger = logger }
+}
+
+// WithCheckInterval overrides how often the client re-checks for a newer
+// version. Defaults to VersionCheckInterval.
+func WithCheckInterval(interval time.Duration) Option {
+	return func(c *usageClient) { c.checkInterval = interval }
+}
+
+// WithOutdatedHandler is invoked with the check response whenever a
+// newer version is available, in place of the default fmt.Printf to
+// stdout. This lets library consumers (CLIs, long-running servers) route
+// the signal into their own logger or UI.
+func WithOutdatedHandler(handler func(CheckResponse)) Option {
+	return func(c *usageClient) { c.outdatedHandler = handler }
+}
+
+// NewUsageClient creates a UsageClient, applying any given Options.
+func NewUsageClient(opts ...Option) *usageClient {
+	c := &usageClient{
+		checkInterval: VersionCheckInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 type usageClient struct {
+	httpClient      *http.Client
+	parentCtx       context.Context
+	logger          func(level, msg string, kv ...interface{})
+	checkInterval   time.Duration
+	outdatedHandler func(CheckResponse)
+
+	cancel   context.CancelFunc
+	doneCh   chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func (c *usageClient) log(level, msg string, kv ...interface{}) {
+	if c.logger != nil {
+		c.logger(level, msg, kv...)
+	}
 }
 
-func (c *usageClient) Start(name, version string) {
+func (c *usageClient) Start(name, version string) StopFunc {
 	now := time.Now()
+
+	parent := c.parentCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c.cancel = cancel
+
 	// starts the background check process
-	callCheck(name, version, now)
+	c.doneCh = callCheck(ctx, c, name, version, now)
 
-	// Do an immediate check and report within the next 30 seconds
+	// Do an immediate report and check in the background,
+	// aborting any in-flight retry if the client is stopped first.
+	c.wg.Add(1)
 	go func() {
-		callReport(name, version, now)
-		callCheckOnceNow(name, version)
+		defer c.wg.Done()
+		callReport(ctx, c, name, version, now)
+		callCheckOnceNow(ctx, c, name, version)
 	}()
 
+	return c.stop
+}
+
+// stop is the StopFunc returned by Start. It's safe to call more than
+// once - only the first call cancels the context and closes doneCh - and
+// every call waits for the background goroutines to finish before
+// returning.
+func (c *usageClient) stop() error {
+	c.stopOnce.Do(func() {
+		c.cancel()
+		close(c.doneCh)
+	})
+	c.wg.Wait()
+	return nil
 }
@@ -0,0 +1,106 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUsageClient_StopHaltsIntervalLoop(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		baseExpectedResponse.Outdated = false
+		baseExpectedResponse.Alerts = nil
+		json.NewEncoder(w).Encode(baseExpectedResponse)
+	}))
+	defer srv.Close()
+
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	os.Setenv("CHECKPOINT_URL", srv.URL)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	c := NewUsageClient(WithCheckInterval(50 * time.Millisecond))
+	stop := c.Start("test", "1.0")
+
+	// Let a handful of interval ticks happen.
+	time.Sleep(250 * time.Millisecond)
+
+	if err := stop(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if atomic.LoadInt32(&hits) == 0 {
+		t.Fatalf("expected at least one check before Stop")
+	}
+
+	// The loop may have already been mid-tick when Stop was called, so
+	// allow one straggler, but the count must stabilize quickly - it
+	// must not keep climbing once the loop has actually halted.
+	time.Sleep(150 * time.Millisecond)
+	settled := atomic.LoadInt32(&hits)
+	time.Sleep(150 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&hits); got != settled {
+		t.Fatalf("expected checks to stop after Stop, went from %d to %d", settled, got)
+	}
+}
+
+func TestUsageClient_StopIsSafeToCallTwice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(baseExpectedResponse)
+	}))
+	defer srv.Close()
+
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	os.Setenv("CHECKPOINT_URL", srv.URL)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	c := NewUsageClient(WithCheckInterval(time.Hour))
+	stop := c.Start("test", "1.0")
+
+	if err := stop(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestUsageClient_WithOutdatedHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&CheckResponse{
+			Product:            "test",
+			CurrentVersion:     "2.0",
+			CurrentDownloadURL: downloadUrl,
+			Outdated:           true,
+		})
+	}))
+	defer srv.Close()
+
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	os.Setenv("CHECKPOINT_URL", srv.URL)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	calledCh := make(chan CheckResponse, 1)
+	c := NewUsageClient(
+		WithCheckInterval(time.Hour),
+		WithOutdatedHandler(func(resp CheckResponse) {
+			calledCh <- resp
+		}),
+	)
+	stop := c.Start("test", "1.0")
+	defer stop()
+
+	select {
+	case resp := <-calledCh:
+		if resp.CurrentVersion != "2.0" {
+			t.Fatalf("bad: %#v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for outdated handler")
+	}
+}
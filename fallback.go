@@ -0,0 +1,272 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointCoolOff is how long a failed endpoint is skipped for once its
+// failure score crosses endpointUnhealthyScore.
+const endpointCoolOff = 1 * time.Minute
+
+// endpointDecayHalfLife controls how quickly an endpoint's failure score
+// decays back towards zero once it stops failing.
+const endpointDecayHalfLife = 5 * time.Minute
+
+// endpointUnhealthyScore is the failure score above which an endpoint is
+// considered unhealthy and subject to the cool-off window. A single
+// failure seeds a score of 1.0 (see recordFailure), which decays with
+// endpointDecayHalfLife; endpointUnhealthyScore is set to exactly the
+// decayed value of that seed at endpointCoolOff, so one failure alone is
+// enough to keep an endpoint cooling off for the whole window, not just
+// the instant it failed.
+var endpointUnhealthyScore = math.Pow(0.5, float64(endpointCoolOff)/float64(endpointDecayHalfLife))
+
+// lastGoodEndpointFile is the name of the file, within the config dir,
+// that records the last endpoint a Client successfully talked to.
+const lastGoodEndpointFile = "checkpoint-endpoint"
+
+// endpointHealth tracks a single endpoint's recent failure history. The
+// failure score decays exponentially, so a flaky endpoint recovers on its
+// own once it starts succeeding (or simply goes quiet) again.
+type endpointHealth struct {
+	failureScore float64
+	lastFailure  time.Time
+}
+
+func (h *endpointHealth) decayedScore(now time.Time) float64 {
+	if h == nil || h.lastFailure.IsZero() {
+		return 0
+	}
+	elapsed := now.Sub(h.lastFailure)
+	halfLives := float64(elapsed) / float64(endpointDecayHalfLife)
+	return h.failureScore * math.Pow(0.5, halfLives)
+}
+
+// Client wraps the package-level check/report functions with support for
+// multiple checkpoint endpoints (CHECKPOINT_URLS or a programmatic list),
+// falling back to the next endpoint when an earlier one is unhealthy. This
+// lets operators run mirrors of the checkpoint service - for example a
+// self-hosted instance alongside solo.io's - and keep telemetry flowing
+// if one of them goes down.
+type Client struct {
+	// Endpoints is the ordered list of checkpoint base URLs to try. If
+	// empty, NewClient populates it from CHECKPOINT_URLS (or CHECKPOINT_URL,
+	// or the package default, in that order).
+	Endpoints []string
+
+	mu     sync.Mutex
+	health map[string]*endpointHealth
+}
+
+// NewClient creates a Client. If endpoints is empty, it's populated from
+// the CHECKPOINT_URLS environment variable (a comma-separated list),
+// falling back to CHECKPOINT_URL, and finally the package default.
+func NewClient(endpoints ...string) *Client {
+	if len(endpoints) == 0 {
+		endpoints = endpointsFromEnv()
+	}
+	return &Client{
+		Endpoints: endpoints,
+		health:    make(map[string]*endpointHealth),
+	}
+}
+
+// endpointsFromEnv builds the default endpoint list from the environment.
+func endpointsFromEnv() []string {
+	if v := os.Getenv("CHECKPOINT_URLS"); v != "" {
+		var out []string
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				out = append(out, e)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	if v := os.Getenv("CHECKPOINT_URL"); v != "" {
+		return []string{v}
+	}
+	return []string{checkpointURL}
+}
+
+// Check runs a check against the Client's endpoints in their configured
+// order (the last known-good one first), skipping any that are cooling
+// off, and stops at the first one that succeeds.
+func (c *Client) Check(p *CheckParams) (*CheckResponse, error) {
+	return c.CheckContext(context.Background(), p)
+}
+
+// CheckContext is Check with a caller-supplied context.
+func (c *Client) CheckContext(ctx context.Context, p *CheckParams) (*CheckResponse, error) {
+	var result *CheckResponse
+	err := c.withEndpoint(func(base *url.URL) error {
+		resp, err := checkAgainst(ctx, p, base)
+		if err != nil {
+			return err
+		}
+		result = resp
+		return nil
+	})
+	return result, err
+}
+
+// Report runs a report against the Client's endpoints in their configured
+// order (the last known-good one first), skipping any that are cooling
+// off, and stops at the first one that succeeds.
+func (c *Client) Report(r *ReportParams) (*ReportResponse, error) {
+	return c.ReportContext(context.Background(), r)
+}
+
+// ReportContext is Report with a caller-supplied context.
+func (c *Client) ReportContext(ctx context.Context, r *ReportParams) (*ReportResponse, error) {
+	var result *ReportResponse
+	err := c.withEndpoint(func(base *url.URL) error {
+		resp, err := reportAgainst(ctx, r, base)
+		if err != nil {
+			return err
+		}
+		result = resp
+		return nil
+	})
+	return result, err
+}
+
+// withEndpoint tries call against each of the Client's endpoints in turn,
+// skipping any that are currently cooling off from recent failures,
+// recording the outcome of each attempt, and persisting the first
+// endpoint that succeeds as the preferred one for future process starts.
+func (c *Client) withEndpoint(call func(base *url.URL) error) error {
+	endpoints := c.orderedEndpoints()
+	if len(endpoints) == 0 {
+		return fmt.Errorf("checkpoint: no endpoints configured")
+	}
+
+	var lastErr error
+	for _, ep := range endpoints {
+		if c.isCoolingOff(ep) {
+			continue
+		}
+
+		base, err := url.Parse(ep)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := call(base); err != nil {
+			c.recordFailure(ep)
+			lastErr = err
+			continue
+		}
+
+		c.recordSuccess(ep)
+		c.persistLastGoodEndpoint(ep)
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("checkpoint: all endpoints are cooling off")
+	}
+	return lastErr
+}
+
+// orderedEndpoints returns the Client's endpoints with the last
+// known-good one (if any, and if still configured) moved to the front.
+func (c *Client) orderedEndpoints() []string {
+	good := c.readLastGoodEndpoint()
+	if good == "" || !c.hasEndpoint(good) {
+		return c.Endpoints
+	}
+
+	ordered := make([]string, 0, len(c.Endpoints))
+	ordered = append(ordered, good)
+	for _, ep := range c.Endpoints {
+		if ep != good {
+			ordered = append(ordered, ep)
+		}
+	}
+	return ordered
+}
+
+// hasEndpoint reports whether endpoint is one of c.Endpoints, so a
+// persisted last-good endpoint from before an operator reconfigured
+// CHECKPOINT_URLS doesn't get resurrected.
+func (c *Client) hasEndpoint(endpoint string) bool {
+	for _, ep := range c.Endpoints {
+		if ep == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) isCoolingOff(endpoint string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := c.health[endpoint]
+	if h == nil {
+		return false
+	}
+
+	now := time.Now()
+	return now.Sub(h.lastFailure) < endpointCoolOff && h.decayedScore(now) >= endpointUnhealthyScore
+}
+
+func (c *Client) recordFailure(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	h := c.health[endpoint]
+	if h == nil {
+		h = &endpointHealth{}
+		c.health[endpoint] = h
+	}
+	h.failureScore = h.decayedScore(now) + 1
+	h.lastFailure = now
+}
+
+func (c *Client) recordSuccess(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := c.health[endpoint]
+	if h == nil {
+		return
+	}
+	h.failureScore = h.decayedScore(time.Now()) / 2
+}
+
+// persistLastGoodEndpoint records endpoint as the preferred one for
+// future process starts, so operators running mirrors don't pay the cost
+// of retrying a down endpoint every time the process restarts.
+func (c *Client) persistLastGoodEndpoint(endpoint string) {
+	dir, err := configDir()
+	if err != nil {
+		return
+	}
+	ioutil.WriteFile(filepath.Join(dir, lastGoodEndpointFile), []byte(endpoint), 0644)
+}
+
+func (c *Client) readLastGoodEndpoint() string {
+	dir, err := configDir()
+	if err != nil {
+		return ""
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, lastGoodEndpointFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,125 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_CheckFallsBackToHealthyEndpoint(t *testing.T) {
+	var badHits, goodHits int32
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		baseExpectedResponse.Outdated = false
+		baseExpectedResponse.Alerts = nil
+		json.NewEncoder(w).Encode(baseExpectedResponse)
+	}))
+	defer good.Close()
+
+	// Disable retries within each endpoint attempt so a 503 from the bad
+	// endpoint fails over to the next endpoint immediately.
+	c := NewClient(bad.URL, good.URL)
+
+	resp, err := c.Check(&CheckParams{
+		Product:     "test",
+		Version:     "1.0",
+		RetryPolicy: &RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Product != "test" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if atomic.LoadInt32(&badHits) != 1 {
+		t.Fatalf("expected exactly one attempt against the bad endpoint, got %d", badHits)
+	}
+	if atomic.LoadInt32(&goodHits) != 1 {
+		t.Fatalf("expected exactly one attempt against the good endpoint, got %d", goodHits)
+	}
+
+	// A second call should prefer the persisted, already-healthy
+	// endpoint and, since the bad endpoint is still within its cool-off
+	// window, never touch it again.
+	if _, err := c.Check(&CheckParams{
+		Product:     "test",
+		Version:     "1.0",
+		RetryPolicy: &RetryPolicy{MaxAttempts: 1},
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if atomic.LoadInt32(&badHits) != 1 {
+		t.Fatalf("expected the bad endpoint to stay skipped while cooling off, got %d hits", badHits)
+	}
+	if atomic.LoadInt32(&goodHits) != 2 {
+		t.Fatalf("expected the second call to go straight to the good endpoint, got %d hits", goodHits)
+	}
+}
+
+func TestClient_isCoolingOffAfterSingleFailure(t *testing.T) {
+	c := NewClient("https://a.example.com")
+
+	c.recordFailure("https://a.example.com")
+
+	if !c.isCoolingOff("https://a.example.com") {
+		t.Fatalf("expected a single failure to open the cool-off window")
+	}
+}
+
+func TestClient_orderedEndpointsIgnoresStalePersistedEndpoint(t *testing.T) {
+	c := NewClient("https://a.example.com", "https://b.example.com")
+
+	dir, err := configDir()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, lastGoodEndpointFile)
+	prev, prevErr := ioutil.ReadFile(path)
+	defer func() {
+		if prevErr == nil {
+			ioutil.WriteFile(path, prev, 0644)
+		} else {
+			os.Remove(path)
+		}
+	}()
+
+	// Simulate a last-known-good endpoint persisted before the operator
+	// reconfigured CHECKPOINT_URLS to drop it.
+	if err := ioutil.WriteFile(path, []byte("https://removed.example.com"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	got := c.orderedEndpoints()
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected the stale persisted endpoint to be ignored, got %#v", got)
+	}
+}
+
+func TestEndpointsFromEnv(t *testing.T) {
+	prevURLs := os.Getenv("CHECKPOINT_URLS")
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	defer os.Setenv("CHECKPOINT_URLS", prevURLs)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	os.Setenv("CHECKPOINT_URLS", "https://a.example.com, https://b.example.com")
+	os.Setenv("CHECKPOINT_URL", "")
+
+	got := endpointsFromEnv()
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("bad: %#v", got)
+	}
+}
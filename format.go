@@ -44,9 +44,8 @@ func getSigfile() string {
 }
 
 // callReport calls a basic version check
-func callReport(product string, version string, t time.Time) {
+func callReport(ctx context.Context, c *usageClient, product string, version string, t time.Time) {
 	sigfile := getSigfile()
-	ctx := context.Background()
 	reportParams := &ReportParams{
 		Product:       product,
 		Version:       version,
@@ -54,11 +53,14 @@ func callReport(product string, version string, t time.Time) {
 		EndTime:       time.Now(),
 		SignatureFile: sigfile,
 		Type:          "r1",
+		HTTPClient:    c.httpClient,
+	}
+	if _, err := report(ctx, reportParams); err != nil {
+		c.log("warn", "checkpoint report failed", "error", err)
 	}
-	report(ctx, reportParams)
 }
 
-func getCheckInputs(product, version string) (*CheckParams, func(resp *CheckResponse, err error)) {
+func getCheckInputs(c *usageClient, product, version string) (*CheckParams, func(resp *CheckResponse, err error)) {
 	signature, err := checkSignature(getSigfile())
 	if err != nil {
 		signature, err = generateSignature()
@@ -67,32 +69,39 @@ func getCheckInputs(product, version string) (*CheckParams, func(resp *CheckResp
 		}
 	}
 	params := &CheckParams{
-		Product:   product,
-		Version:   version,
-		Signature: signature,
-		Type:      "c1",
+		Product:    product,
+		Version:    version,
+		Signature:  signature,
+		Type:       "c1",
+		HTTPClient: c.httpClient,
 	}
 	cb := func(resp *CheckResponse, err error) {
 		if err != nil {
+			c.log("warn", "checkpoint check failed", "error", err)
 			return
 		}
 		if resp.Outdated && resp.CurrentVersion != "" && resp.CurrentVersion != version {
-			fmt.Printf("A new version of %v is available. Please visit %v.\n", product, resp.CurrentDownloadURL)
+			if c.outdatedHandler != nil {
+				c.outdatedHandler(*resp)
+			} else {
+				fmt.Printf("A new version of %v is available. Please visit %v.\n", product, resp.CurrentDownloadURL)
+			}
 		}
 		return
 	}
 	return params, cb
 }
 
-// callCheck calls a basic version check at an interval
-func callCheck(product string, version string, t time.Time) {
-	params, cb := getCheckInputs(product, version)
-	checkInterval(params, VersionCheckInterval, cb)
+// callCheck calls a basic version check at an interval. It returns a
+// channel that, when closed, stops the interval loop.
+func callCheck(ctx context.Context, c *usageClient, product string, version string, t time.Time) chan struct{} {
+	params, cb := getCheckInputs(c, product, version)
+	return checkInterval(ctx, params, c.checkInterval, cb, &c.wg)
 }
 
-// callCheck calls a basic version check at an interval
-func callCheckOnceNow(product string, version string) {
-	params, cb := getCheckInputs(product, version)
-	resp, err := check(params)
+// callCheckOnceNow runs a single, immediate version check.
+func callCheckOnceNow(ctx context.Context, c *usageClient, product string, version string) {
+	params, cb := getCheckInputs(c, product, version)
+	resp, err := checkWithContext(ctx, params)
 	cb(resp, err)
 }
@@ -0,0 +1,139 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+)
+
+// ReportParams are the parameters for configuring a report request, which
+// is a one-way telemetry event (as opposed to check, which expects a
+// response describing the latest release).
+type ReportParams struct {
+	// Signature is some random signature that should be stored and used
+	// to prevent duplicate reports from occurring. This isn't required
+	// but is recommended.
+	Signature string
+
+	// SignatureFile, like CheckParams.SignatureFile, is read (and
+	// generated, if necessary) when Signature isn't set directly.
+	SignatureFile string
+
+	// Product and version identify what's reporting in.
+	Product string `json:"product"`
+	Version string `json:"version"`
+
+	// Arch and OS, like CheckParams, default to runtime.GOARCH/GOOS.
+	Arch string `json:"arch,omitempty"`
+	OS   string `json:"os,omitempty"`
+
+	// StartTime and EndTime bound the period the report covers.
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	// Type is the type of report being sent, e.g. "r1".
+	Type string `json:"type"`
+
+	// RetryPolicy controls how connection errors and 5xx responses are
+	// retried. If nil, defaultRetryPolicy() is used.
+	RetryPolicy *RetryPolicy `json:"-"`
+
+	// HTTPClient overrides the *http.Client used to make the request. If
+	// nil, cleanhttpClient() is used.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// ReportResponse is the response to a report request.
+type ReportResponse struct {
+	Message string `json:"message"`
+}
+
+// report sends a telemetry report to the checkpoint server. Unlike check,
+// the response carries no actionable data for the caller, so errors are
+// the only thing worth inspecting.
+func report(ctx context.Context, r *ReportParams) (*ReportResponse, error) {
+	return reportAgainst(ctx, r, checkpointBaseURL())
+}
+
+// reportAgainst is report against a specific checkpoint endpoint, used
+// directly by Client to try several endpoints in turn.
+func reportAgainst(ctx context.Context, r *ReportParams, base *url.URL) (*ReportResponse, error) {
+	if os.Getenv("CHECKPOINT_DISABLE") != "" {
+		return nil, nil
+	}
+
+	req, err := reportRequestAgainst(r, base)
+	if err != nil {
+		return nil, err
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = cleanhttpClient()
+	}
+	resp, err := doWithRetry(ctx, client, req, r.RetryPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("checkpoint: unexpected status code %d", resp.StatusCode)
+	}
+
+	var result ReportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// reportRequest builds the outgoing HTTP request for a report call against
+// the default (CHECKPOINT_URL) endpoint.
+func reportRequest(r *ReportParams) (*http.Request, error) {
+	return reportRequestAgainst(r, checkpointBaseURL())
+}
+
+// reportRequestAgainst builds the outgoing HTTP request for a report call
+// against a specific base URL, so a Client can try multiple endpoints.
+func reportRequestAgainst(r *ReportParams, base *url.URL) (*http.Request, error) {
+	signature := r.Signature
+	if signature == "" && r.SignatureFile != "" {
+		var err error
+		signature, err = checkSignature(r.SignatureFile)
+		if err != nil {
+			signature, err = generateSignature(r.SignatureFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.Signature = signature
+
+	if r.Arch == "" {
+		r.Arch = runtime.GOARCH
+	}
+	if r.OS == "" {
+		r.OS = runtime.GOOS
+	}
+
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *base
+	u.Path = fmt.Sprintf("/telemetry/%s", r.Product)
+
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how doWithRetry retries a request that failed
+// for a transient reason (connection errors, 5xx responses). Fields left
+// at zero are not filled in from defaultRetryPolicy(): a MaxAttempts
+// below 1 makes a single attempt, and a zero backoff means no wait
+// between attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts to make, including the
+	// first one. A value of 1 disables retrying.
+	MaxAttempts int
+
+	// InitialBackoff is how long to wait before the second attempt.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the backoff before jitter is applied; with the ±10%
+	// jitter, a single wait can be up to 10% longer than MaxBackoff.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the backoff after each failed attempt.
+	Multiplier float64
+}
+
+// defaultRetryPolicy returns the RetryPolicy used when one isn't supplied:
+// 3 attempts, backing off from 500ms up to 8s, doubling each time.
+func defaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     8 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// doWithRetry executes req with client, retrying on connection errors and
+// 5xx responses according to policy. It gives up immediately on a 4xx
+// response or context cancellation, since neither of those is helped by
+// trying again. A nil policy falls back to defaultRetryPolicy().
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy *RetryPolicy) (*http.Response, error) {
+	if policy == nil {
+		policy = defaultRetryPolicy()
+	} else if policy.MaxAttempts < 1 {
+		// A policy with MaxAttempts left at its zero value would never
+		// enter the loop below, leaving us with no response and no error
+		// to report. Treat it the same as MaxAttempts: 1 rather than
+		// silently mutating the caller's policy.
+		p := *policy
+		p.MaxAttempts = 1
+		policy = &p
+	}
+
+	backoff := policy.InitialBackoff
+	var lastErr error
+
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := backoff
+			if wait > policy.MaxBackoff {
+				wait = policy.MaxBackoff
+			}
+			wait = randomStagger(wait, 10)
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		}
+
+		attemptReq := req.Clone(ctx)
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := client.Do(attemptReq)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		if resp.StatusCode >= 500 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("checkpoint: server returned %d", resp.StatusCode)
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
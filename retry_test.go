@@ -0,0 +1,158 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	cases := []struct {
+		name       string
+		failures   int32
+		statusCode int
+		wantErr    bool
+	}{
+		{"succeeds after two 503s", 2, http.StatusServiceUnavailable, false},
+		{"gives up on 4xx immediately", 0, http.StatusBadRequest, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&hits, 1)
+				if n <= tc.failures {
+					w.WriteHeader(tc.statusCode)
+					return
+				}
+				if tc.failures == 0 {
+					w.WriteHeader(tc.statusCode)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			policy := &RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: 10 * time.Millisecond,
+				MaxBackoff:     20 * time.Millisecond,
+				Multiplier:     2,
+			}
+
+			resp, err := doWithRetry(context.Background(), &http.Client{}, req, policy)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if tc.failures > 0 && resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected eventual 200, got %d", resp.StatusCode)
+			}
+			if tc.failures == 0 && resp.StatusCode != tc.statusCode {
+				t.Fatalf("expected %d to be returned as-is, got %d", tc.statusCode, resp.StatusCode)
+			}
+			if tc.failures > 0 && atomic.LoadInt32(&hits) != tc.failures+1 {
+				t.Fatalf("expected %d attempts, got %d", tc.failures+1, atomic.LoadInt32(&hits))
+			}
+			if tc.failures == 0 && atomic.LoadInt32(&hits) != 1 {
+				t.Fatalf("expected a single attempt for a 4xx, got %d", atomic.LoadInt32(&hits))
+			}
+		})
+	}
+}
+
+func TestDoWithRetry_zeroMaxAttemptsStillMakesOneAttempt(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A policy with only InitialBackoff/MaxBackoff set, leaving
+	// MaxAttempts at its zero value, must not make the attempt loop a
+	// no-op: that would return (nil, nil) and panic callers that assume a
+	// non-nil response whenever err is nil.
+	policy := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	resp, err := doWithRetry(context.Background(), &http.Client{}, req, policy)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp == nil {
+		t.Fatalf("expected a non-nil response")
+	}
+	defer resp.Body.Close()
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected exactly one attempt, got %d", got)
+	}
+	if policy.MaxAttempts != 0 {
+		t.Fatalf("expected the caller's policy to be left untouched, got MaxAttempts=%d", policy.MaxAttempts)
+	}
+}
+
+func TestCheck_retriesOn503(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		baseExpectedResponse.Outdated = false
+		baseExpectedResponse.Alerts = nil
+		json.NewEncoder(w).Encode(baseExpectedResponse)
+	}))
+	defer srv.Close()
+
+	prevURL := os.Getenv("CHECKPOINT_URL")
+	os.Setenv("CHECKPOINT_URL", srv.URL)
+	defer os.Setenv("CHECKPOINT_URL", prevURL)
+
+	resp, err := check(&CheckParams{
+		Product: "test",
+		Version: "1.0",
+		RetryPolicy: &RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     20 * time.Millisecond,
+			Multiplier:     2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.Product != "test" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("expected 3 requests (2 failures + 1 success), got %d", got)
+	}
+}
@@ -0,0 +1,49 @@
+package checkpoint
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// checkSignature reads the signature stored at path. If the file doesn't
+// exist or is empty, an error is returned; callers that want a signature
+// no matter what should fall back to generateSignature themselves (see
+// getCheckInputs).
+func checkSignature(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sig := strings.TrimSpace(string(data))
+	if sig == "" {
+		return "", fmt.Errorf("checkpoint: signature file %q is empty", path)
+	}
+	return sig, nil
+}
+
+// generateSignature creates a new random (UUID v4) signature. If path is
+// given, the signature is persisted there so future calls to
+// checkSignature reuse it instead of generating a new one each time.
+func generateSignature(path ...string) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+
+	// Set the version (4) and variant bits per RFC 4122.
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+
+	sig := fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+
+	if len(path) > 0 && path[0] != "" {
+		if err := ioutil.WriteFile(path[0], []byte(sig), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	return sig, nil
+}